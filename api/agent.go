@@ -1,8 +1,10 @@
 package api
 
 import (
+	"bufio"
 	"fmt"
 	"net/url"
+	"strings"
 )
 
 // Agent encapsulates an API client which talks to Nomad's
@@ -12,6 +14,7 @@ type Agent struct {
 
 	// Cache static agent info
 	nodeName   string
+	nodeID     string
 	datacenter string
 	region     string
 }
@@ -22,6 +25,25 @@ func (c *Client) Agent() *Agent {
 	return &Agent{client: c}
 }
 
+// AgentSelf is the typed response for the /v1/agent/self endpoint.
+type AgentSelf struct {
+	Member AgentMember                  `json:"member"`
+	Config AgentConfig                  `json:"config"`
+	Stats  map[string]map[string]string `json:"stats"`
+}
+
+// AgentConfig holds the static configuration of the running agent.
+type AgentConfig struct {
+	Region          string
+	Datacenter      string
+	NodeName        string
+	NodeID          string
+	Server          bool
+	BootstrapExpect int
+	Revision        string
+	Version         string
+}
+
 // Self is used to query the /v1/agent/self endpoint and
 // returns information specific to the running agent.
 func (a *Agent) Self() (map[string]map[string]interface{}, error) {
@@ -56,6 +78,44 @@ func (a *Agent) populateCache(info map[string]map[string]interface{}) {
 	}
 }
 
+// SelfTyped is used to query the /v1/agent/self endpoint and
+// returns a typed view of the running agent, avoiding the fragile
+// double map assertions that Self() requires of its callers.
+func (a *Agent) SelfTyped() (*AgentSelf, error) {
+	var out *AgentSelf
+
+	// Query the self endpoint on the agent
+	_, err := a.client.query("/v1/agent/self", &out, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed querying self endpoint: %s", err)
+	}
+
+	// Populate the cache for faster queries
+	a.populateCacheTyped(out)
+
+	return out, nil
+}
+
+// populateCacheTyped mirrors populateCache, but for the typed
+// SelfTyped() response.
+func (a *Agent) populateCacheTyped(self *AgentSelf) {
+	if self == nil {
+		return
+	}
+	if a.nodeName == "" {
+		a.nodeName = self.Member.Name
+	}
+	if a.nodeID == "" {
+		a.nodeID = self.Config.NodeID
+	}
+	if a.datacenter == "" {
+		a.datacenter = self.Member.Tags["dc"]
+	}
+	if a.region == "" {
+		a.region = self.Member.Tags["region"]
+	}
+}
+
 // NodeName is used to query the Nomad agent for its node name.
 func (a *Agent) NodeName() (string, error) {
 	// Return from cache if we have it
@@ -68,6 +128,18 @@ func (a *Agent) NodeName() (string, error) {
 	return a.nodeName, err
 }
 
+// NodeID is used to query the Nomad agent for its node ID.
+func (a *Agent) NodeID() (string, error) {
+	// Return from cache if we have it
+	if a.nodeID != "" {
+		return a.nodeID, nil
+	}
+
+	// Query the node ID
+	_, err := a.SelfTyped()
+	return a.nodeID, err
+}
+
 // Datacenter is used to return the name of the datacenter which
 // the agent is a member of.
 func (a *Agent) Datacenter() (string, error) {
@@ -97,28 +169,244 @@ func (a *Agent) Region() (string, error) {
 // via the gossip protocol. Multiple addresses may be specified.
 // We attempt to join all of the hosts in the list. If one or
 // more nodes have a successful result, no error is returned.
+// Use JoinWithOptions for per-address results and cross-region
+// join options.
 func (a *Agent) Join(addrs ...string) error {
+	resp, err := a.JoinWithOptions(addrs, nil)
+	if err != nil {
+		return err
+	}
+	if resp.NumNodes == 0 {
+		return fmt.Errorf("failed joining: %s", joinErrors(resp.Addresses))
+	}
+	return nil
+}
+
+// joinErrors renders the per-address errors from a failed join
+// attempt into a single message for Join's all-or-nothing error.
+func joinErrors(results []AddressJoinResult) string {
+	var errs []string
+	for _, r := range results {
+		if !r.Joined && r.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", r.Address, r.Error))
+		}
+	}
+	return strings.Join(errs, "; ")
+}
+
+// JoinOptions are used to customize the behavior of JoinWithOptions,
+// for example to join across the WAN or into a different region or
+// network area.
+type JoinOptions struct {
+	// WAN, if true, joins the server(s) on the WAN gossip pool
+	// instead of the LAN pool.
+	WAN bool
+
+	// Replay, if true, replays any previously entered user events.
+	Replay bool
+
+	// Region is the target region or network area to join across,
+	// for cross-region joins. If empty, the local region is used.
+	Region string
+}
+
+// JoinWithOptions is used to instruct a server node to join another
+// server via the gossip protocol, attempting each address
+// independently and reporting per-address outcomes. This allows
+// callers to retry only the addresses that actually failed.
+func (a *Agent) JoinWithOptions(addrs []string, opts *JoinOptions) (*JoinResponse, error) {
 	// Accumulate the addresses
 	v := url.Values{}
 	for _, addr := range addrs {
 		v.Add("address", addr)
 	}
+	if opts != nil {
+		if opts.WAN {
+			v.Set("wan", "1")
+		}
+		if opts.Replay {
+			v.Set("replay", "1")
+		}
+		if opts.Region != "" {
+			v.Set("region", opts.Region)
+		}
+	}
 
 	// Send the join request
-	var resp joinResponse
+	var resp JoinResponse
 	_, err := a.client.write("/v1/agent/join?"+v.Encode(), nil, &resp, nil)
 	if err != nil {
-		return fmt.Errorf("failed joining: %s", err)
+		return nil, fmt.Errorf("failed joining: %s", err)
 	}
-	if resp.Error != "" {
-		return fmt.Errorf("failed joining: %s", resp.Error)
+	return &resp, nil
+}
+
+// JoinResponse is used to decode the response we get while sending
+// a member join request.
+type JoinResponse struct {
+	NumNodes  int                 `json:"num_nodes"`
+	Addresses []AddressJoinResult `json:"addresses"`
+}
+
+// AddressJoinResult captures the outcome of attempting to join a
+// single address as part of a JoinResponse.
+type AddressJoinResult struct {
+	Address string `json:"address"`
+	Joined  bool   `json:"joined"`
+	Error   string `json:"error"`
+}
+
+// ForceLeave is used to eject an existing node from the gossip pool,
+// for example a failed server that never rejoins on its own.
+func (a *Agent) ForceLeave(node string) error {
+	v := url.Values{}
+	v.Set("node", node)
+
+	_, err := a.client.write("/v1/agent/force-leave?"+v.Encode(), nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to force leave: %s", err)
 	}
 	return nil
 }
 
-// joinResponse is used to decode the response we get while
-// sending a member join request.
-type joinResponse struct {
-	NumNodes int    `json:"num_nodes"`
-	Error    string `json:"error"`
+// Leave is used to have the agent gracefully leave the gossip pool.
+// Use ForceLeave to eject a different node instead.
+func (a *Agent) Leave() error {
+	_, err := a.client.write("/v1/agent/leave", nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to leave: %s", err)
+	}
+	return nil
+}
+
+// Members is used to query all of the known server members.
+func (a *Agent) Members() ([]*AgentMember, error) {
+	var resp serverMembers
+
+	// Query the members endpoint on the agent
+	_, err := a.client.query("/v1/agent/members", &resp, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed querying members endpoint: %s", err)
+	}
+
+	return resp.Members, nil
+}
+
+// serverMembers is used to decode the response we get while
+// querying the list of known gossip members.
+type serverMembers struct {
+	Members []*AgentMember
+}
+
+// AgentMember represents a cluster member known to the agent's
+// gossip pool, mirroring the Serf member model.
+type AgentMember struct {
+	Name        string
+	Addr        string
+	Port        uint16
+	Tags        map[string]string
+	Status      int
+	ProtocolMin uint8
+	ProtocolMax uint8
+	ProtocolCur uint8
+	DelegateMin uint8
+	DelegateMax uint8
+	DelegateCur uint8
+}
+
+// Serf status constants, mirroring serf.MemberStatus.
+const (
+	StatusNone int = iota
+	StatusAlive
+	StatusLeaving
+	StatusLeft
+	StatusFailed
+)
+
+// StatusPretty returns the human readable string for the
+// member's status.
+func (m *AgentMember) StatusPretty() string {
+	switch m.Status {
+	case StatusNone:
+		return "none"
+	case StatusAlive:
+		return "alive"
+	case StatusLeaving:
+		return "leaving"
+	case StatusLeft:
+		return "left"
+	case StatusFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Monitor streams the agent's logs at the given level, returning a
+// channel of parsed log lines and a channel of errors. It opens a
+// long-lived GET against /v1/agent/monitor and scans the response
+// body line-by-line until stopCh is closed or the server disconnects.
+func (a *Agent) Monitor(logLevel string, stopCh <-chan struct{}) (<-chan string, <-chan error) {
+	logCh := make(chan string, 64)
+	errCh := make(chan error, 1)
+
+	v := url.Values{}
+	if logLevel != "" {
+		v.Set("loglevel", logLevel)
+	}
+
+	r, err := a.client.newRequest("GET", "/v1/agent/monitor?"+v.Encode())
+	if err != nil {
+		errCh <- err
+		close(logCh)
+		close(errCh)
+		return logCh, errCh
+	}
+
+	_, resp, err := requireOK(a.client.doRequest(r))
+	if err != nil {
+		errCh <- err
+		close(logCh)
+		close(errCh)
+		return logCh, errCh
+	}
+
+	// done is closed once the scan loop returns on its own, so the
+	// watcher goroutine below isn't left blocked on stopCh forever
+	// when the server disconnects instead of the caller stopping us.
+	done := make(chan struct{})
+
+	// Closing the body unblocks the scanner below, so honor
+	// stopCh by closing it out from under the read.
+	go func() {
+		select {
+		case <-stopCh:
+			resp.Body.Close()
+		case <-done:
+		}
+	}()
+
+	go func() {
+		defer close(done)
+		defer resp.Body.Close()
+		defer close(logCh)
+		defer close(errCh)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			select {
+			case <-stopCh:
+				return
+			case logCh <- scanner.Text():
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			select {
+			case errCh <- err:
+			case <-stopCh:
+			}
+		}
+	}()
+
+	return logCh, errCh
 }
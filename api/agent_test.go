@@ -0,0 +1,402 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAgentMember_StatusPretty(t *testing.T) {
+	cases := []struct {
+		status int
+		want   string
+	}{
+		{StatusNone, "none"},
+		{StatusAlive, "alive"},
+		{StatusLeaving, "leaving"},
+		{StatusLeft, "left"},
+		{StatusFailed, "failed"},
+		{99, "unknown"},
+	}
+
+	for _, tc := range cases {
+		m := &AgentMember{Status: tc.status}
+		if got := m.StatusPretty(); got != tc.want {
+			t.Errorf("StatusPretty() with status %d = %q, want %q", tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestAgent_Members(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"Members": [
+				{
+					"Name": "node-1.global",
+					"Addr": "10.0.0.1",
+					"Port": 4648,
+					"Tags": {"dc": "dc1", "region": "global"},
+					"Status": 1,
+					"ProtocolMin": 1,
+					"ProtocolMax": 5,
+					"ProtocolCur": 2,
+					"DelegateMin": 2,
+					"DelegateMax": 4,
+					"DelegateCur": 4
+				}
+			]
+		}`)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&Config{Address: ts.URL})
+	if err != nil {
+		t.Fatalf("failed creating client: %v", err)
+	}
+
+	members, err := c.Agent().Members()
+	if err != nil {
+		t.Fatalf("Members() returned error: %v", err)
+	}
+	if len(members) != 1 {
+		t.Fatalf("got %d members, want 1", len(members))
+	}
+
+	m := members[0]
+	if m.Name != "node-1.global" {
+		t.Errorf("Name = %q, want %q", m.Name, "node-1.global")
+	}
+	if m.Addr != "10.0.0.1" {
+		t.Errorf("Addr = %q, want %q", m.Addr, "10.0.0.1")
+	}
+	if m.Port != 4648 {
+		t.Errorf("Port = %d, want %d", m.Port, 4648)
+	}
+	if m.Tags["dc"] != "dc1" || m.Tags["region"] != "global" {
+		t.Errorf("Tags = %+v, want dc=dc1 region=global", m.Tags)
+	}
+	if m.Status != StatusAlive {
+		t.Errorf("Status = %d, want %d (alive)", m.Status, StatusAlive)
+	}
+	if m.StatusPretty() != "alive" {
+		t.Errorf("StatusPretty() = %q, want %q", m.StatusPretty(), "alive")
+	}
+	if m.ProtocolMin != 1 || m.ProtocolMax != 5 || m.ProtocolCur != 2 {
+		t.Errorf("protocol versions = %d/%d/%d, want 1/5/2", m.ProtocolMin, m.ProtocolMax, m.ProtocolCur)
+	}
+	if m.DelegateMin != 2 || m.DelegateMax != 4 || m.DelegateCur != 4 {
+		t.Errorf("delegate versions = %d/%d/%d, want 2/4/4", m.DelegateMin, m.DelegateMax, m.DelegateCur)
+	}
+}
+
+func TestAgent_ForceLeave(t *testing.T) {
+	cases := []struct {
+		node string
+		want string
+	}{
+		{"node-1", "node=node-1"},
+		{"node with spaces", "node=node+with+spaces"},
+		{"node/with/slashes", "node=node%2Fwith%2Fslashes"},
+	}
+
+	for _, tc := range cases {
+		var gotQuery string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotQuery = r.URL.RawQuery
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		c, err := NewClient(&Config{Address: ts.URL})
+		if err != nil {
+			ts.Close()
+			t.Fatalf("failed creating client: %v", err)
+		}
+
+		err = c.Agent().ForceLeave(tc.node)
+		ts.Close()
+		if err != nil {
+			t.Fatalf("ForceLeave(%q) returned error: %v", tc.node, err)
+		}
+		if gotQuery != tc.want {
+			t.Errorf("ForceLeave(%q) query = %q, want %q", tc.node, gotQuery, tc.want)
+		}
+	}
+}
+
+func TestAgent_JoinWithOptions(t *testing.T) {
+	cases := []struct {
+		name  string
+		addrs []string
+		opts  *JoinOptions
+		want  url.Values
+	}{
+		{
+			name:  "no options",
+			addrs: []string{"10.0.0.1", "10.0.0.2"},
+			opts:  nil,
+			want:  url.Values{"address": {"10.0.0.1", "10.0.0.2"}},
+		},
+		{
+			name:  "wan and replay",
+			addrs: []string{"10.0.0.1"},
+			opts:  &JoinOptions{WAN: true, Replay: true},
+			want:  url.Values{"address": {"10.0.0.1"}, "wan": {"1"}, "replay": {"1"}},
+		},
+		{
+			name:  "cross region",
+			addrs: []string{"10.0.0.1"},
+			opts:  &JoinOptions{Region: "west"},
+			want:  url.Values{"address": {"10.0.0.1"}, "region": {"west"}},
+		},
+	}
+
+	for _, tc := range cases {
+		var gotQuery url.Values
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotQuery = r.URL.Query()
+			json.NewEncoder(w).Encode(&JoinResponse{NumNodes: len(tc.addrs)})
+		}))
+
+		c, err := NewClient(&Config{Address: ts.URL})
+		if err != nil {
+			ts.Close()
+			t.Fatalf("%s: failed creating client: %v", tc.name, err)
+		}
+
+		resp, err := c.Agent().JoinWithOptions(tc.addrs, tc.opts)
+		ts.Close()
+		if err != nil {
+			t.Fatalf("%s: JoinWithOptions returned error: %v", tc.name, err)
+		}
+		if resp.NumNodes != len(tc.addrs) {
+			t.Errorf("%s: NumNodes = %d, want %d", tc.name, resp.NumNodes, len(tc.addrs))
+		}
+		if gotQuery.Encode() != tc.want.Encode() {
+			t.Errorf("%s: query = %q, want %q", tc.name, gotQuery.Encode(), tc.want.Encode())
+		}
+	}
+}
+
+func TestAgent_Join_FailsWhenNoNodesJoined(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&JoinResponse{
+			NumNodes: 0,
+			Addresses: []AddressJoinResult{
+				{Address: "10.0.0.1", Joined: false, Error: "connection refused"},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&Config{Address: ts.URL})
+	if err != nil {
+		t.Fatalf("failed creating client: %v", err)
+	}
+
+	err = c.Agent().Join("10.0.0.1")
+	if err == nil {
+		t.Fatal("expected an error when no nodes joined")
+	}
+}
+
+// TestAgent_Monitor_ServerDisconnect exercises the path where the
+// server closes the connection on its own, without the caller ever
+// closing stopCh. Before the watcher goroutine was fixed to also
+// select on an internal done channel, this left one goroutine
+// blocked on stopCh forever per call.
+func TestAgent_Monitor_ServerDisconnect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "log line 1")
+		fmt.Fprintln(w, "log line 2")
+	}))
+	defer ts.Close()
+
+	// Disable keep-alives so the transport doesn't itself leave a
+	// pooled connection's read-loop goroutine running in the
+	// background for its idle timeout, which would otherwise show
+	// up as phantom growth unrelated to Monitor.
+	httpClient := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	c, err := NewClient(&Config{Address: ts.URL, HttpClient: httpClient})
+	if err != nil {
+		t.Fatalf("failed creating client: %v", err)
+	}
+
+	// Warm up the transport so any one-time setup goroutines (DNS
+	// resolver, connection dialing) are already accounted for in
+	// the baseline below, rather than showing up as Monitor "leaks".
+	warmup, err := httpClient.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("warm-up request failed: %v", err)
+	}
+	warmup.Body.Close()
+	before := runtime.NumGoroutine()
+
+	stopCh := make(chan struct{})
+	logCh, errCh := c.Agent().Monitor("DEBUG", stopCh)
+
+	var lines []string
+	timeout := time.After(2 * time.Second)
+drain:
+	for {
+		select {
+		case line, ok := <-logCh:
+			if !ok {
+				break drain
+			}
+			lines = append(lines, line)
+		case err := <-errCh:
+			if err != nil {
+				t.Fatalf("unexpected error from Monitor: %v", err)
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for Monitor to finish after the server disconnected")
+		}
+	}
+
+	if len(lines) != 2 {
+		t.Errorf("got %d log lines, want 2", len(lines))
+	}
+
+	// Poll instead of a single fixed sleep+compare: the watcher and
+	// scan goroutines need a moment to unwind after logCh/errCh
+	// close, but a real leak will never settle back down.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if after := runtime.NumGoroutine(); after <= before {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("goroutine count did not return to baseline (%d) within 2s after Monitor finished; likely a leak", before)
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestAgent_Monitor_Stop(t *testing.T) {
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "log line 1")
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		<-block
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	c, err := NewClient(&Config{Address: ts.URL})
+	if err != nil {
+		t.Fatalf("failed creating client: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	logCh, _ := c.Agent().Monitor("DEBUG", stopCh)
+
+	select {
+	case <-logCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the first log line")
+	}
+
+	close(stopCh)
+
+	select {
+	case _, ok := <-logCh:
+		if ok {
+			t.Fatal("expected logCh to close after stopCh was closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for Monitor to stop after stopCh was closed")
+	}
+}
+
+func TestAgent_SelfTyped(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"member": {
+				"Name": "node-1.global",
+				"Addr": "10.0.0.1",
+				"Port": 4648,
+				"Tags": {"dc": "dc1", "region": "global"},
+				"Status": 1,
+				"ProtocolMin": 1,
+				"ProtocolMax": 5,
+				"ProtocolCur": 2,
+				"DelegateMin": 2,
+				"DelegateMax": 4,
+				"DelegateCur": 4
+			},
+			"config": {
+				"Region": "global",
+				"Datacenter": "dc1",
+				"NodeName": "node-1.global",
+				"NodeID": "9d5d1c8b-9b2e-4f6a-9b6b-9c2b8b9b9b9b",
+				"Server": true,
+				"BootstrapExpect": 3,
+				"Revision": "abc1234",
+				"Version": "0.8.0"
+			},
+			"stats": {
+				"nomad": {"server": "true"}
+			}
+		}`)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&Config{Address: ts.URL})
+	if err != nil {
+		t.Fatalf("failed creating client: %v", err)
+	}
+
+	self, err := c.Agent().SelfTyped()
+	if err != nil {
+		t.Fatalf("SelfTyped() returned error: %v", err)
+	}
+
+	if self.Member.Name != "node-1.global" {
+		t.Errorf("Member.Name = %q, want %q", self.Member.Name, "node-1.global")
+	}
+	if self.Config.Region != "global" {
+		t.Errorf("Config.Region = %q, want %q", self.Config.Region, "global")
+	}
+	if self.Config.NodeID != "9d5d1c8b-9b2e-4f6a-9b6b-9c2b8b9b9b9b" {
+		t.Errorf("Config.NodeID = %q, want %q", self.Config.NodeID, "9d5d1c8b-9b2e-4f6a-9b6b-9c2b8b9b9b9b")
+	}
+	if !self.Config.Server {
+		t.Error("Config.Server = false, want true")
+	}
+	if self.Config.BootstrapExpect != 3 {
+		t.Errorf("Config.BootstrapExpect = %d, want 3", self.Config.BootstrapExpect)
+	}
+	if self.Stats["nomad"]["server"] != "true" {
+		t.Errorf("Stats[nomad][server] = %q, want %q", self.Stats["nomad"]["server"], "true")
+	}
+
+	agent := c.Agent()
+	id, err := agent.NodeID()
+	if err != nil {
+		t.Fatalf("NodeID() returned error: %v", err)
+	}
+	if id != "9d5d1c8b-9b2e-4f6a-9b6b-9c2b8b9b9b9b" {
+		t.Errorf("NodeID() = %q, want %q", id, "9d5d1c8b-9b2e-4f6a-9b6b-9c2b8b9b9b9b")
+	}
+
+	// agent is a fresh handle with an empty cache, so NodeID() goes
+	// through SelfTyped, which also caches name/dc/region.
+	if agent.nodeName != "node-1.global" {
+		t.Errorf("cached nodeName = %q, want %q", agent.nodeName, "node-1.global")
+	}
+	if agent.datacenter != "dc1" {
+		t.Errorf("cached datacenter = %q, want %q", agent.datacenter, "dc1")
+	}
+	if agent.region != "global" {
+		t.Errorf("cached region = %q, want %q", agent.region, "global")
+	}
+}